@@ -0,0 +1,462 @@
+// Package filter implements nexus's match/exclude/highlight expressions:
+// plain substrings, /regex/ literals, and structured predicates like
+// "level>=warn" or "field.status>=500", combinable with && || !.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/schmich/nexus/layout"
+)
+
+// Context is the line a predicate is evaluated against, along with its
+// structured fields, if the line parsed as JSON or logfmt.
+type Context struct {
+	Line   string
+	Record *layout.Record
+}
+
+// Expr is a compiled match/exclude/highlight expression.
+type Expr interface {
+	Eval(ctx Context) bool
+}
+
+// Parse compiles a match/exclude/highlight entry into an Expr. Entries are
+// either a plain substring, a /regex/ literal, or a structured predicate
+// (level>=warn, field.status>=500, line-length>200, word-count<3),
+// combinable with && / || / !.
+func Parse(expr string) (Expr, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected trailing input in filter expression %q", expr)
+	}
+
+	return e, nil
+}
+
+// MustParse is like Parse but panics on error; useful for compiling
+// expressions known to be valid at init time.
+func MustParse(expr string) Expr {
+	e, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+type tokenKind int
+
+const (
+	tokAnd tokenKind = iota
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+	tokAtom
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(s string) ([]token, error) {
+	runes := []rune(s)
+	n := len(runes)
+	var tokens []token
+
+	i := 0
+	for i < n {
+		for i < n && runes[i] == ' ' {
+			i++
+		}
+		if i >= n {
+			break
+		}
+
+		switch {
+		case i+1 < n && runes[i] == '&' && runes[i+1] == '&':
+			tokens = append(tokens, token{kind: tokAnd})
+			i += 2
+		case i+1 < n && runes[i] == '|' && runes[i+1] == '|':
+			tokens = append(tokens, token{kind: tokOr})
+			i += 2
+		case runes[i] == '!':
+			tokens = append(tokens, token{kind: tokNot})
+			i++
+		case runes[i] == '(':
+			tokens = append(tokens, token{kind: tokLParen})
+			i++
+		case runes[i] == ')':
+			tokens = append(tokens, token{kind: tokRParen})
+			i++
+		default:
+			start := i
+			inRegex := false
+			for i < n {
+				if runes[i] == '/' {
+					inRegex = !inRegex
+					i++
+					continue
+				}
+				if !inRegex {
+					if i+1 < n && runes[i] == '&' && runes[i+1] == '&' {
+						break
+					}
+					if i+1 < n && runes[i] == '|' && runes[i+1] == '|' {
+						break
+					}
+					if runes[i] == ')' {
+						break
+					}
+				}
+				i++
+			}
+			text := strings.TrimSpace(string(runes[start:i]))
+			if text == "" {
+				return nil, fmt.Errorf("empty term in filter expression %q", s)
+			}
+			tokens = append(tokens, token{kind: tokAtom, text: text})
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOr {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokAnd {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	}
+
+	if t.kind == tokNot {
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+
+	return p.parseAtom()
+}
+
+func (p *parser) parseAtom() (Expr, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	}
+
+	if t.kind == tokLParen {
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		close, ok := p.peek()
+		if !ok || close.kind != tokRParen {
+			return nil, fmt.Errorf("missing closing paren in filter expression")
+		}
+		p.pos++
+		return e, nil
+	}
+
+	if t.kind != tokAtom {
+		return nil, fmt.Errorf("expected filter term, got operator")
+	}
+	p.pos++
+
+	return parseLeaf(t.text)
+}
+
+var predicatePattern = regexp.MustCompile(`^([a-zA-Z_][\w.-]*)\s*(>=|<=|==|!=|>|<)\s*(.+)$`)
+
+func parseLeaf(text string) (Expr, error) {
+	if len(text) >= 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", text, err)
+		}
+		return regexExpr{re}, nil
+	}
+
+	if m := predicatePattern.FindStringSubmatch(text); m != nil {
+		return predicateExpr{field: m[1], op: m[2], value: m[3]}, nil
+	}
+
+	return substringExpr{text}, nil
+}
+
+type substringExpr struct {
+	text string
+}
+
+func (e substringExpr) Eval(ctx Context) bool {
+	return strings.Contains(ctx.Line, e.text)
+}
+
+type regexExpr struct {
+	re *regexp.Regexp
+}
+
+func (e regexExpr) Eval(ctx Context) bool {
+	return e.re.MatchString(ctx.Line)
+}
+
+type notExpr struct {
+	e Expr
+}
+
+func (e notExpr) Eval(ctx Context) bool {
+	return !e.e.Eval(ctx)
+}
+
+type andExpr struct {
+	left, right Expr
+}
+
+func (e andExpr) Eval(ctx Context) bool {
+	return e.left.Eval(ctx) && e.right.Eval(ctx)
+}
+
+type orExpr struct {
+	left, right Expr
+}
+
+func (e orExpr) Eval(ctx Context) bool {
+	return e.left.Eval(ctx) || e.right.Eval(ctx)
+}
+
+type predicateExpr struct {
+	field string
+	op    string
+	value string
+}
+
+func (e predicateExpr) Eval(ctx Context) bool {
+	switch e.field {
+	case "line-length":
+		return compareNumeric(e.op, float64(len(ctx.Line)), e.value)
+	case "word-count":
+		return compareNumeric(e.op, float64(len(strings.Fields(ctx.Line))), e.value)
+	case "level":
+		if ctx.Record == nil || ctx.Record.Level == "" {
+			return false
+		}
+		rank, ok := layout.LevelRank(ctx.Record.Level)
+		if !ok {
+			return false
+		}
+		want, ok := layout.LevelRank(e.value)
+		if !ok {
+			return false
+		}
+		return compareOp(e.op, float64(rank), float64(want))
+	default:
+		if !strings.HasPrefix(e.field, "field.") {
+			return false
+		}
+		if ctx.Record == nil {
+			return false
+		}
+		name := strings.TrimPrefix(e.field, "field.")
+		for _, kv := range ctx.Record.Fields {
+			if kv.Key != name {
+				continue
+			}
+			if a, err := strconv.ParseFloat(kv.Value, 64); err == nil {
+				return compareNumeric(e.op, a, e.value)
+			}
+			switch e.op {
+			case "==":
+				return kv.Value == e.value
+			case "!=":
+				return kv.Value != e.value
+			default:
+				return false
+			}
+		}
+		return false
+	}
+}
+
+func compareNumeric(op string, a float64, raw string) bool {
+	b, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return false
+	}
+	return compareOp(op, a, b)
+}
+
+const (
+	inverseOn  = "\x1b[7m"
+	inverseOff = "\x1b[0m"
+)
+
+type span struct {
+	start, end int
+}
+
+// Highlight wraps every match of e's substring/regex leaves in ctx.Line
+// with inverse-video ANSI codes. Structured predicates (level>=warn, ...)
+// have nothing to point at in the raw text, so they contribute no spans;
+// neither do substring/regex leaves under a !.
+func Highlight(e Expr, ctx Context) string {
+	spans := matchSpans(e, ctx.Line)
+	if len(spans) == 0 {
+		return ctx.Line
+	}
+
+	spans = mergeSpans(spans)
+
+	var out strings.Builder
+	last := 0
+	for _, s := range spans {
+		out.WriteString(ctx.Line[last:s.start])
+		out.WriteString(inverseOn)
+		out.WriteString(ctx.Line[s.start:s.end])
+		out.WriteString(inverseOff)
+		last = s.end
+	}
+	out.WriteString(ctx.Line[last:])
+
+	return out.String()
+}
+
+func matchSpans(e Expr, line string) []span {
+	switch v := e.(type) {
+	case substringExpr:
+		var spans []span
+		for i := 0; i+len(v.text) <= len(line) && v.text != ""; {
+			idx := strings.Index(line[i:], v.text)
+			if idx < 0 {
+				break
+			}
+			start := i + idx
+			spans = append(spans, span{start, start + len(v.text)})
+			i = start + len(v.text)
+		}
+		return spans
+	case regexExpr:
+		var spans []span
+		for _, m := range v.re.FindAllStringIndex(line, -1) {
+			spans = append(spans, span{m[0], m[1]})
+		}
+		return spans
+	case notExpr:
+		return nil
+	case andExpr:
+		return append(matchSpans(v.left, line), matchSpans(v.right, line)...)
+	case orExpr:
+		return append(matchSpans(v.left, line), matchSpans(v.right, line)...)
+	default:
+		return nil
+	}
+}
+
+func mergeSpans(spans []span) []span {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start <= last.end {
+			if s.end > last.end {
+				last.end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+func compareOp(op string, a, b float64) bool {
+	switch op {
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/schmich/nexus/layout"
+)
+
+func mustEval(t *testing.T, expr string, ctx Context) bool {
+	t.Helper()
+	e, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse(%q) error: %v", expr, err)
+	}
+	return e.Eval(ctx)
+}
+
+func TestParsePrecedence(t *testing.T) {
+	cases := []struct {
+		expr string
+		line string
+		want bool
+	}{
+		// && binds tighter than ||.
+		{"foo && bar || baz", "baz only", true},
+		{"foo && bar || baz", "foo only", false},
+		{"foo && bar || baz", "foo bar", true},
+		// ! binds tighter than && and ||.
+		{"!foo && bar", "bar", true},
+		{"!foo && bar", "foo bar", false},
+		{"!foo || bar", "foo", false},
+		{"!foo || bar", "foo bar", true},
+		// parens override default precedence.
+		{"foo && (bar || baz)", "foo baz", true},
+		{"foo && (bar || baz)", "foo qux", false},
+		{"!(foo && bar)", "foo bar", false},
+		{"!(foo && bar)", "foo", true},
+	}
+
+	for _, c := range cases {
+		got := mustEval(t, c.expr, Context{Line: c.line})
+		if got != c.want {
+			t.Errorf("Parse(%q).Eval(%q) = %v, want %v", c.expr, c.line, got, c.want)
+		}
+	}
+}
+
+func TestParseSubstring(t *testing.T) {
+	if !mustEval(t, "connection reset", Context{Line: "got connection reset by peer"}) {
+		t.Error("expected substring match")
+	}
+	if mustEval(t, "connection reset", Context{Line: "all good"}) {
+		t.Error("expected no match")
+	}
+}
+
+func TestParseRegex(t *testing.T) {
+	cases := []struct {
+		expr string
+		line string
+		want bool
+	}{
+		{`/^ERROR/`, "ERROR: disk full", true},
+		{`/^ERROR/`, "WARN: disk full", false},
+		{`/\d{3}/`, "status=500", true},
+		{`/\d{3}/`, "status=ok", false},
+	}
+
+	for _, c := range cases {
+		got := mustEval(t, c.expr, Context{Line: c.line})
+		if got != c.want {
+			t.Errorf("Parse(%q).Eval(%q) = %v, want %v", c.expr, c.line, got, c.want)
+		}
+	}
+}
+
+func TestParseInvalidRegex(t *testing.T) {
+	if _, err := Parse(`/[/`); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestPredicateLineLength(t *testing.T) {
+	cases := []struct {
+		expr string
+		line string
+		want bool
+	}{
+		{"line-length>5", "short", false},
+		{"line-length>5", "longer line", true},
+		{"line-length<=5", "short", true},
+		{"line-length==5", "short", true},
+	}
+
+	for _, c := range cases {
+		got := mustEval(t, c.expr, Context{Line: c.line})
+		if got != c.want {
+			t.Errorf("Parse(%q).Eval(line=%q) = %v, want %v", c.expr, c.line, got, c.want)
+		}
+	}
+}
+
+func TestPredicateWordCount(t *testing.T) {
+	cases := []struct {
+		expr string
+		line string
+		want bool
+	}{
+		{"word-count<3", "one two", true},
+		{"word-count<3", "one two three", false},
+		{"word-count>=4", "one two three four", true},
+	}
+
+	for _, c := range cases {
+		got := mustEval(t, c.expr, Context{Line: c.line})
+		if got != c.want {
+			t.Errorf("Parse(%q).Eval(line=%q) = %v, want %v", c.expr, c.line, got, c.want)
+		}
+	}
+}
+
+func TestPredicateLevel(t *testing.T) {
+	cases := []struct {
+		expr  string
+		level string
+		want  bool
+	}{
+		{"level>=warn", "ERROR", true},
+		{"level>=warn", "WARN", true},
+		{"level>=warn", "INFO", false},
+		{"level==info", "INFO", true},
+		{"level!=info", "INFO", false},
+	}
+
+	for _, c := range cases {
+		ctx := Context{Record: &layout.Record{Level: c.level}}
+		got := mustEval(t, c.expr, ctx)
+		if got != c.want {
+			t.Errorf("Parse(%q).Eval(level=%q) = %v, want %v", c.expr, c.level, got, c.want)
+		}
+	}
+
+	// No parsed record, no level: level predicates never match.
+	if mustEval(t, "level>=info", Context{Line: "plain text"}) {
+		t.Error("expected level predicate to fail without a parsed record")
+	}
+}
+
+func TestPredicateField(t *testing.T) {
+	ctx := Context{Record: &layout.Record{
+		Fields: []layout.KV{
+			{Key: "status", Value: "500"},
+			{Key: "user", Value: "alice"},
+		},
+	}}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"field.status>=500", true},
+		{"field.status<500", false},
+		{"field.user==alice", true},
+		{"field.user!=alice", false},
+		{"field.missing==anything", false},
+	}
+
+	for _, c := range cases {
+		got := mustEval(t, c.expr, ctx)
+		if got != c.want {
+			t.Errorf("Parse(%q).Eval = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package filter
+
+import "sync"
+
+// entry pairs a compiled Expr with the raw text it was parsed from, so it
+// can be located again when the REPL asks to remove it.
+type entry struct {
+	text string
+	expr Expr
+}
+
+// Set holds the match/exclude/highlight expressions in effect for a source
+// (or globally), and can be mutated at runtime from the REPL.
+type Set struct {
+	mu        sync.RWMutex
+	match     []entry
+	exclude   []entry
+	highlight []entry
+
+	suppressed int
+}
+
+func NewSet() *Set {
+	return &Set{}
+}
+
+// AddMatch, AddExclude, and AddHighlight compile text and add it to the
+// respective list.
+func (s *Set) AddMatch(text string) error     { return s.add(&s.match, text) }
+func (s *Set) AddExclude(text string) error   { return s.add(&s.exclude, text) }
+func (s *Set) AddHighlight(text string) error { return s.add(&s.highlight, text) }
+
+func (s *Set) add(list *[]entry, text string) error {
+	expr, err := Parse(text)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*list = append(*list, entry{text, expr})
+
+	return nil
+}
+
+// RemoveMatch, RemoveExclude, and RemoveHighlight drop the first entry
+// matching text, if any.
+func (s *Set) RemoveMatch(text string)     { s.remove(&s.match, text) }
+func (s *Set) RemoveExclude(text string)   { s.remove(&s.exclude, text) }
+func (s *Set) RemoveHighlight(text string) { s.remove(&s.highlight, text) }
+
+func (s *Set) remove(list *[]entry, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, e := range *list {
+		if e.text == text {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return
+		}
+	}
+}
+
+// Allows reports whether ctx passes this set: it must match at least one
+// match expression (if any are configured) and none of the exclude
+// expressions. A rejected line increments the suppression counter.
+func (s *Set) Allows(ctx Context) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.match) > 0 {
+		matched := false
+		for _, e := range s.match {
+			if e.expr.Eval(ctx) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			s.suppressed++
+			return false
+		}
+	}
+
+	for _, e := range s.exclude {
+		if e.expr.Eval(ctx) {
+			s.suppressed++
+			return false
+		}
+	}
+
+	return true
+}
+
+// HighlightLine wraps every match of every highlight expression in
+// ctx.Line with inverse-video ANSI codes.
+func (s *Set) HighlightLine(ctx Context) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	line := ctx.Line
+	for _, e := range s.highlight {
+		line = Highlight(e.expr, Context{Line: line, Record: ctx.Record})
+	}
+
+	return line
+}
+
+// TakeSuppressed returns the number of lines suppressed since the last
+// call and resets the counter, for periodic "N lines suppressed" banners.
+func (s *Set) TakeSuppressed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.suppressed
+	s.suppressed = 0
+
+	return n
+}
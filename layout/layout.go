@@ -0,0 +1,292 @@
+// Package layout renders log records in the various on-screen layouts nexus
+// supports: plain inline lines, the original header+message multiline
+// format, and the column-aligned "columns" format with per-level colors.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+type Mode string
+
+const (
+	Inline    Mode = "inline"
+	Multiline Mode = "multiline"
+	Columns   Mode = "columns"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case Inline, Multiline, Columns:
+		return Mode(s), nil
+	case "":
+		return Multiline, nil
+	default:
+		return "", fmt.Errorf("unknown layout %q (want inline, multiline, or columns)", s)
+	}
+}
+
+// KV is a single key/value pair pulled out of a structured log line, in the
+// order it was encountered.
+type KV struct {
+	Key   string
+	Value string
+}
+
+// Record is the set of fields nexus recognizes in a structured (JSON or
+// logfmt) log line.
+type Record struct {
+	Time    string
+	Level   string
+	Message string
+	Fields  []KV
+}
+
+var timeKeys = []string{"ts", "time", "timestamp", "@timestamp"}
+var levelKeys = []string{"level", "lvl", "severity"}
+var msgKeys = []string{"msg", "message"}
+
+// Parse attempts to interpret line as a structured (JSON or logfmt) log
+// record, returning ok = false if it looks like plain text.
+func Parse(line string) (rec *Record, ok bool) {
+	if rec, ok = parseJSON(line); ok {
+		return rec, true
+	}
+
+	return parseLogfmt(line)
+}
+
+func parseJSON(line string) (*Record, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return nil, false
+	}
+
+	rec := extract(fields, func(v interface{}) string {
+		return fmt.Sprintf("%v", v)
+	})
+
+	return rec, true
+}
+
+func parseLogfmt(line string) (*Record, bool) {
+	pairs := splitLogfmt(line)
+	if len(pairs) == 0 {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{}, len(pairs))
+	order := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		if _, exists := fields[p.Key]; !exists {
+			order = append(order, p.Key)
+		}
+		fields[p.Key] = p.Value
+	}
+
+	rec := extract(fields, func(v interface{}) string {
+		return v.(string)
+	})
+
+	// Preserve logfmt's source order for the kv tail instead of the random
+	// map order extract() would otherwise see.
+	ordered := make([]KV, 0, len(rec.Fields))
+	for _, key := range order {
+		if v, ok := fields[key].(string); ok && isExtra(key) {
+			ordered = append(ordered, KV{key, v})
+		}
+	}
+	rec.Fields = ordered
+
+	return rec, true
+}
+
+func isExtra(key string) bool {
+	for _, k := range append(append(append([]string{}, timeKeys...), levelKeys...), msgKeys...) {
+		if key == k {
+			return false
+		}
+	}
+	return true
+}
+
+func extract(fields map[string]interface{}, str func(interface{}) string) *Record {
+	rec := &Record{}
+
+	for _, k := range timeKeys {
+		if v, ok := fields[k]; ok {
+			rec.Time = str(v)
+			break
+		}
+	}
+
+	for _, k := range levelKeys {
+		if v, ok := fields[k]; ok {
+			rec.Level = strings.ToUpper(str(v))
+			break
+		}
+	}
+
+	for _, k := range msgKeys {
+		if v, ok := fields[k]; ok {
+			rec.Message = str(v)
+			break
+		}
+	}
+
+	known := map[string]bool{}
+	for _, k := range append(append(append([]string{}, timeKeys...), levelKeys...), msgKeys...) {
+		known[k] = true
+	}
+
+	for k, v := range fields {
+		if known[k] {
+			continue
+		}
+		rec.Fields = append(rec.Fields, KV{k, str(v)})
+	}
+
+	return rec
+}
+
+// splitLogfmt splits a logfmt line ("key=value key2=\"quoted value\"") into
+// its key/value pairs. Tokens without an '=' are ignored.
+func splitLogfmt(line string) []KV {
+	var pairs []KV
+
+	var key, value strings.Builder
+	inValue := false
+	inQuotes := false
+
+	flush := func() {
+		if key.Len() > 0 && inValue {
+			pairs = append(pairs, KV{key.String(), value.String()})
+		}
+		key.Reset()
+		value.Reset()
+		inValue = false
+	}
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == '=' && !inValue && !inQuotes:
+			inValue = true
+		case r == ' ' && !inQuotes:
+			flush()
+		case inValue:
+			value.WriteRune(r)
+		default:
+			key.WriteRune(r)
+		}
+	}
+	flush()
+
+	return pairs
+}
+
+var levelColors = map[string]color.RGBColor{
+	"DEBUG":   color.RGB(120, 120, 120),
+	"INFO":    color.RGB(80, 170, 220),
+	"WARN":    color.RGB(230, 180, 60),
+	"WARNING": color.RGB(230, 180, 60),
+	"ERROR":   color.RGB(220, 80, 80),
+	"FATAL":   color.RGB(255, 255, 255),
+}
+
+// LevelColor returns the color associated with level (DEBUG/INFO/WARN/
+// ERROR/FATAL), falling back to a neutral gray for anything else.
+func LevelColor(level string) color.RGBColor {
+	if c, ok := levelColors[strings.ToUpper(level)]; ok {
+		return c
+	}
+	return color.RGB(150, 150, 150)
+}
+
+var levelRank = map[string]int{
+	"DEBUG":   0,
+	"INFO":    1,
+	"WARN":    2,
+	"WARNING": 2,
+	"ERROR":   3,
+	"FATAL":   4,
+}
+
+// LevelRank returns level's severity rank (DEBUG lowest, FATAL highest) so
+// callers can compare levels with >=, and false if level isn't recognized.
+func LevelRank(level string) (int, bool) {
+	rank, ok := levelRank[strings.ToUpper(level)]
+	return rank, ok
+}
+
+// ColumnsLayout lays out records in fixed-width badge/timestamp/level
+// columns followed by a wrapped, indented message column.
+type ColumnsLayout struct {
+	SourceWidth int
+	TimeWidth   int
+	LevelWidth  int
+}
+
+const minMessageWidth = 20
+
+// messageWidth returns how much room is left for the message column given a
+// terminal of the given width, never shrinking below minMessageWidth.
+func (c ColumnsLayout) messageWidth(termWidth int) int {
+	room := termWidth - c.Indent()
+	if room < minMessageWidth {
+		return minMessageWidth
+	}
+	return room
+}
+
+// Indent is the left padding continuation lines of a wrapped message get:
+// the badge/timestamp/level columns, each printed after a separating space,
+// plus the space before the message.
+func (c ColumnsLayout) Indent() int {
+	return 1 + c.SourceWidth + 1 + c.TimeWidth + 1 + c.LevelWidth + 1
+}
+
+// Wrap breaks message into lines no wider than the message column, breaking
+// on word boundaries where possible.
+func (c ColumnsLayout) Wrap(message string, termWidth int) []string {
+	return wrap(message, c.messageWidth(termWidth))
+}
+
+func wrap(text string, width int) []string {
+	if width <= 0 || len(text) <= width {
+		return []string{text}
+	}
+
+	var lines []string
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{text}
+	}
+
+	var line strings.Builder
+	for _, word := range words {
+		if line.Len() > 0 && line.Len()+1+len(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+
+	return lines
+}
@@ -1,20 +1,29 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gookit/color"
 	"github.com/hpcloud/tail"
 	"golang.org/x/sys/unix"
+
+	"github.com/schmich/nexus/filter"
+	"github.com/schmich/nexus/layout"
+	"github.com/schmich/nexus/tui"
 )
 
 // Source path specification:
@@ -23,36 +32,90 @@ import (
 //    relative to pwd
 //    file globbing e.g. "/var/log/*.log"
 
-// isolate to single log, select which logs to show
-// filtering (interrupts and prompts for filter)
+// isolate to single log, select which logs to show -- done, see --interactive source toggling
+// filtering (interrupts and prompts for filter) -- done, see filter package and :filter/:exclude
 // groups (e.g. crons, ...) disable/enable
 // print warning for inaccessible files (permission) and missing files
-// highlighting certain words (e.g. error/fatal/warning)
-// allow running commands and logging output (e.g. `dmesg`, `journalctl -fu foo.service`)
+// highlighting certain words (e.g. error/fatal/warning) -- done, see source.Highlight and :highlight
+// allow running commands and logging output (e.g. `dmesg`, `journalctl -fu foo.service`) -- done, see source.Command
 // limit number of initial lines shown from each source
 // option: prepend timestamps to each message
 // layout options
 //    long lines: truncate, split
-//    single line logging (source + message combined), multi-line logging (source and message on separate lines)
+//    single line logging (source + message combined), multi-line logging (source and message on separate lines) -- done, see --layout
 //    multi-line: don't print header unless source has changed or enough time has elapsed since last log message
 // commands
-//    pause/resume logging
-//    filtering
-//    highlighting
-//    list sources with colors/names/paths
+//    pause/resume logging -- done, see --interactive (space)
+//    filtering -- done, see repl() and --interactive (f)
+//    highlighting -- done, see repl()
+//    list sources with colors/names/paths -- done, see --interactive status bar
 // option to suppress source names, acts as tail -f ... across a bunch of files
-// ability to specify highlight at runtime (highlight bg yellow)
+// ability to specify highlight at runtime (highlight bg yellow) -- done, see :highlight (inverse video only, no color choice)
 // command-line options for the above
 // ability to show logs by name in config (e.g. nexus --source php/errors --source laravel)
+// reload nexus.json on change: add/remove sources, restyle without restarting -- done, see watcher.go and registry.go
 // bug: ctrl-c while running on FormulateDevServer2 while initial tail is happening
 // bug: fail when running via ssh (ssh vm nexus), problems with detecting terminal size
 
 type source struct {
-	Name       string  `json:"name"`
-	Path       string  `json:"path"`
-	Background *[3]int `json:"bg"`
-	Foreground *[3]int `json:"fg"`
-	Truncate   bool    `json:"truncate"`
+	Name       string   `json:"name"`
+	Path       string   `json:"path"`
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	Env        []string `json:"env"`
+	Cwd        string   `json:"cwd"`
+	RestartOn  string   `json:"restart_on"`
+	Backoff    int      `json:"backoff_seconds"`
+	MaxBackoff int      `json:"max_backoff_seconds"`
+	Grace      int      `json:"grace_period_seconds"`
+	Background *[3]int  `json:"bg"`
+	Foreground *[3]int  `json:"fg"`
+	Truncate   bool     `json:"truncate"`
+	Layout     string   `json:"layout"`
+	Level      string   `json:"level"`
+	Match      []string `json:"match"`
+	Exclude    []string `json:"exclude"`
+	Highlight  []string `json:"highlight"`
+}
+
+// filterSet compiles src's match/exclude/highlight lists into a filter.Set.
+// An entry that fails to compile is skipped with a warning rather than
+// aborting startup, since one bad expression shouldn't take the source down.
+func (src *source) filterSet() *filter.Set {
+	set := filter.NewSet()
+
+	for _, m := range src.Match {
+		if err := set.AddMatch(m); err != nil {
+			fmt.Printf("nexus: %s: bad match expression %q: %v\n", src.Name, m, err)
+		}
+	}
+	for _, e := range src.Exclude {
+		if err := set.AddExclude(e); err != nil {
+			fmt.Printf("nexus: %s: bad exclude expression %q: %v\n", src.Name, e, err)
+		}
+	}
+	for _, h := range src.Highlight {
+		if err := set.AddHighlight(h); err != nil {
+			fmt.Printf("nexus: %s: bad highlight expression %q: %v\n", src.Name, h, err)
+		}
+	}
+
+	return set
+}
+
+// layoutMode resolves the effective layout for src, falling back to the
+// global --layout flag when the source doesn't set its own.
+func (src *source) layoutMode(global layout.Mode) layout.Mode {
+	if src.Layout == "" {
+		return global
+	}
+
+	mode, err := layout.ParseMode(src.Layout)
+	if err != nil {
+		return global
+	}
+
+	return mode
 }
 
 type config struct {
@@ -109,66 +172,271 @@ func getTerminalSize() (int, int) {
 type record struct {
 	Source *source
 	Line   *tail.Line
+	Parsed *layout.Record
+	// SubTag distinguishes multiple streams from the same source, e.g. a
+	// Command source's "stderr" alongside its default stdout stream.
+	SubTag string
 }
 
-func writer(records <-chan *record, stop <-chan bool) {
-	width, height := getTerminalSize()
+// badgeName is the label printed in place of Source.Name: the bare source
+// name, or "name/subtag" for a tagged sub-stream such as a command's stderr.
+func (r *record) badgeName() string {
+	if r.SubTag == "" {
+		return r.Source.Name
+	}
+	return r.Source.Name + "/" + r.SubTag
+}
 
-	var lastSource *source
-	streak := 0
+// suppressionCounts tracks, per source name, how many lines the filter
+// stage has dropped since the last time the writer reported them.
+type suppressionCounts struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newSuppressionCounts() *suppressionCounts {
+	return &suppressionCounts{counts: make(map[string]int)}
+}
 
-	primaries := make(map[*source]color.RGBColor)
-	styles := make(map[*source]color.RGBStyle)
+func (s *suppressionCounts) add(sourceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[sourceName]++
+}
 
-	getStyle := func(src *source) (color.RGBColor, color.RGBStyle) {
-		var primary color.RGBColor
-		var style color.RGBStyle
-		var ok bool
+// drain returns the counts accumulated since the last drain and resets them.
+func (s *suppressionCounts) drain() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if primary, ok = primaries[src]; !ok {
-			var r, g, b int
-			if src.Background == nil {
-				hash := sha256.Sum256([]byte(src.Path))
-				r, g, b = int(hash[0]), int(hash[1]), int(hash[2])
-			} else {
-				r, g, b = src.Background[0], src.Background[1], src.Background[2]
+	counts := s.counts
+	s.counts = make(map[string]int)
+
+	return counts
+}
+
+// filterStage sits between the tail goroutines and the writer: it parses
+// each line once, drops anything rejected by the source's or the global
+// filter set, and tallies suppressions for the periodic summary banner.
+func filterStage(in <-chan *record, out chan<- *record, reg *registry, global *filter.Set, suppressed *suppressionCounts, stop <-chan bool) {
+	for {
+		select {
+		case <-stop:
+			return
+
+		case rec := <-in:
+			if parsed, ok := layout.Parse(rec.Line.Text); ok {
+				rec.Parsed = parsed
 			}
 
-			var fg, bg color.RGBColor
+			ctx := filter.Context{Line: rec.Line.Text, Record: rec.Parsed}
 
-			primary = color.RGB(uint8(r), uint8(g), uint8(b))
-			primaries[src] = primary
-			bg = primary
+			if set := reg.filterSet(rec.Source.Name); set != nil && !set.Allows(ctx) {
+				suppressed.add(rec.Source.Name)
+				continue
+			}
 
-			if src.Foreground == nil {
-				if perceivedLightness(r, g, b) >= 50 {
-					fg = color.RGB(0, 0, 0)
-				} else {
-					fg = color.RGB(255, 255, 255)
-				}
-			} else {
-				r, g, b = src.Foreground[0], src.Foreground[1], src.Foreground[2]
-				fg = color.RGB(uint8(r), uint8(g), uint8(b))
+			if !global.Allows(ctx) {
+				suppressed.add(rec.Source.Name)
+				continue
 			}
 
-			style = *color.NewRGBStyle(fg, bg)
-			styles[src] = style
-		} else {
-			style = styles[src]
+			out <- rec
+		}
+	}
+}
+
+// repl reads runtime filter commands from stdin: ":filter +term" / "-term"
+// adds/removes a global match expression, ":exclude" and ":highlight" do
+// the same for the exclude and highlight lists.
+func repl(global *filter.Set) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		var rest string
+		var add func(string) error
+		var remove func(string)
+
+		switch {
+		case strings.HasPrefix(line, ":filter"):
+			rest = strings.TrimSpace(strings.TrimPrefix(line, ":filter"))
+			add, remove = global.AddMatch, global.RemoveMatch
+		case strings.HasPrefix(line, ":exclude"):
+			rest = strings.TrimSpace(strings.TrimPrefix(line, ":exclude"))
+			add, remove = global.AddExclude, global.RemoveExclude
+		case strings.HasPrefix(line, ":highlight"):
+			rest = strings.TrimSpace(strings.TrimPrefix(line, ":highlight"))
+			add, remove = global.AddHighlight, global.RemoveHighlight
+		default:
+			continue
 		}
 
+		if rest == "" {
+			continue
+		}
+
+		switch rest[0] {
+		case '+':
+			if err := add(strings.TrimSpace(rest[1:])); err != nil {
+				fmt.Println("nexus: filter error:", err)
+			}
+		case '-':
+			remove(strings.TrimSpace(rest[1:]))
+		}
+	}
+}
+
+// passesLevel reports whether rec clears src's minimum level, if one is
+// configured. Unparsed lines and unknown levels always pass through.
+func passesLevel(src *source, rec *layout.Record) bool {
+	if src.Level == "" || rec == nil || rec.Level == "" {
+		return true
+	}
+
+	min, ok := layout.LevelRank(src.Level)
+	if !ok {
+		return true
+	}
+
+	rank, ok := layout.LevelRank(rec.Level)
+	if !ok {
+		return true
+	}
+
+	return rank >= min
+}
+
+// sourceColor returns src's badge background color: its configured
+// Background, or a color hashed from its path so the same source always
+// gets the same color across runs.
+func sourceColor(src *source) (r, g, b int) {
+	if src.Background != nil {
+		return src.Background[0], src.Background[1], src.Background[2]
+	}
+
+	hash := sha256.Sum256([]byte(src.Path))
+	return int(hash[0]), int(hash[1]), int(hash[2])
+}
+
+// styleCache memoizes the primary (background) color and full badge style
+// for each source, so the perceived-lightness/foreground computation only
+// runs once per source. Shared by the plain writer and --interactive's
+// badges so both render sources identically; the --interactive status bar
+// takes its background color straight from sourceColor.
+//
+// Keyed by source name rather than *source pointer: a config reload swaps
+// in a new *source for a name that didn't change color, and keying by name
+// lets that source keep its cached style instead of recomputing (and
+// potentially drifting) it.
+type styleCache struct {
+	mu        sync.RWMutex
+	primaries map[string]color.RGBColor
+	styles    map[string]color.RGBStyle
+}
+
+func newStyleCache() *styleCache {
+	return &styleCache{
+		primaries: make(map[string]color.RGBColor),
+		styles:    make(map[string]color.RGBStyle),
+	}
+}
+
+func (c *styleCache) getStyle(src *source) (color.RGBColor, color.RGBStyle) {
+	c.mu.RLock()
+	primary, ok := c.primaries[src.Name]
+	style := c.styles[src.Name]
+	c.mu.RUnlock()
+	if ok {
 		return primary, style
 	}
 
+	r, g, b := sourceColor(src)
+
+	primary = color.RGB(uint8(r), uint8(g), uint8(b))
+
+	var fg color.RGBColor
+	if src.Foreground == nil {
+		if perceivedLightness(r, g, b) >= 50 {
+			fg = color.RGB(0, 0, 0)
+		} else {
+			fg = color.RGB(255, 255, 255)
+		}
+	} else {
+		fg = color.RGB(uint8(src.Foreground[0]), uint8(src.Foreground[1]), uint8(src.Foreground[2]))
+	}
+
+	style = *color.NewRGBStyle(fg, primary)
+
+	c.mu.Lock()
+	c.primaries[src.Name] = primary
+	c.styles[src.Name] = style
+	c.mu.Unlock()
+
+	return primary, style
+}
+
+// invalidate drops name's cached style so the next getStyle recomputes it,
+// used after a config reload changes that source's colors.
+func (c *styleCache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.primaries, name)
+	delete(c.styles, name)
+}
+
+func writer(records <-chan *record, stop <-chan bool, globalLayout layout.Mode, reg *registry, global *filter.Set, suppressed *suppressionCounts, styleCache *styleCache) {
+	width, height := getTerminalSize()
+
+	var lastSource *source
+	streak := 0
+
+	getStyle := styleCache.getStyle
+
+	columns := layout.ColumnsLayout{SourceWidth: 16, TimeWidth: 19, LevelWidth: 5}
+
+	summary := time.NewTicker(5 * time.Second)
+	defer summary.Stop()
+
 	for {
 		select {
 		case <-stop:
 			return
 
+		case <-summary.C:
+			for name, n := range suppressed.drain() {
+				color.RGB(120, 120, 120).Printf(" %d lines suppressed from %s\n", n, name)
+			}
+
 		case record := <-records:
+			if !passesLevel(record.Source, record.Parsed) {
+				continue
+			}
+
+			mode := record.Source.layoutMode(globalLayout)
+
+			if mode == layout.Columns {
+				primary, style := getStyle(record.Source)
+				printColumns(columns, width, primary, style, record, reg, global)
+				lastSource = record.Source
+				continue
+			}
+
+			if mode == layout.Inline {
+				primary, style := getStyle(record.Source)
+				style.Printf(" %s ", record.badgeName())
+				primary.Printf(" ")
+				printLine(record, width, reg, global)
+				lastSource = record.Source
+				continue
+			}
+
+			// Multiline: print a header whenever the source changes, or
+			// periodically once a streak of same-source lines fills the
+			// screen, so the header scrolls back into view.
 			if record.Source != lastSource {
 				primary, style := getStyle(record.Source)
-				style.Printf(" %s ", record.Source.Name)
+				style.Printf(" %s ", record.badgeName())
 				primary.Printf(" %s", record.Source.Path)
 				fmt.Println()
 				streak = 1
@@ -176,25 +444,118 @@ func writer(records <-chan *record, stop <-chan bool) {
 				streak++
 				if streak == height {
 					primary, style := getStyle(record.Source)
-					style.Printf(" %s (cont) ", record.Source.Name)
+					style.Printf(" %s (cont) ", record.badgeName())
 					primary.Printf(" %s", record.Source.Path)
 					fmt.Println()
 					streak = 1
 				}
 			}
 
-			if record.Source.Truncate && len(record.Line.Text) >= width {
-				fmt.Println(record.Line.Text[0 : width-1])
-			} else {
-				fmt.Println(record.Line.Text)
-			}
+			printLine(record, width, reg, global)
 
 			lastSource = record.Source
 		}
 	}
 }
 
+// highlightText applies record's source-level and global highlight
+// expressions to text, wrapping matches in inverse-video ANSI.
+func highlightText(record *record, text string, reg *registry, global *filter.Set) string {
+	ctx := filter.Context{Line: text, Record: record.Parsed}
+
+	if set := reg.filterSet(record.Source.Name); set != nil {
+		text = set.HighlightLine(ctx)
+		ctx.Line = text
+	}
+
+	return global.HighlightLine(ctx)
+}
+
+// printLine prints a record's raw text, truncating to the terminal width
+// when the source opts in.
+func printLine(record *record, width int, reg *registry, global *filter.Set) {
+	text := record.Line.Text
+	if record.Source.Truncate && len(text) >= width {
+		text = text[0 : width-1]
+	}
+
+	fmt.Println(highlightText(record, text, reg, global))
+}
+
+// printColumns renders a record in the fixed-width badge/timestamp/level/
+// message layout, wrapping and indenting the message so continuation lines
+// stay under the message column instead of restarting at column 0.
+func printColumns(columns layout.ColumnsLayout, width int, primary color.RGBColor, style color.RGBStyle, record *record, reg *registry, global *filter.Set) {
+	badge := record.badgeName()
+	if len(badge) > columns.SourceWidth {
+		badge = badge[0:columns.SourceWidth]
+	}
+	style.Printf(" %-*s", columns.SourceWidth, badge)
+
+	ts, level, message := "", "", record.Line.Text
+	if record.Parsed != nil {
+		ts, level, message = record.Parsed.Time, record.Parsed.Level, record.Parsed.Message
+	}
+	if len(ts) > columns.TimeWidth {
+		ts = ts[0:columns.TimeWidth]
+	}
+
+	primary.Printf(" %-*s", columns.TimeWidth, ts)
+
+	if level != "" {
+		layout.LevelColor(level).Printf(" %-*s", columns.LevelWidth, level)
+	} else {
+		fmt.Printf(" %-*s", columns.LevelWidth, "")
+	}
+
+	// Wrap the plain message before highlighting so the invisible ANSI
+	// escape bytes highlightText inserts don't count toward the wrap width
+	// and a highlighted span can't get split across a line break.
+	lines := columns.Wrap(message, width)
+	for i, line := range lines {
+		line = highlightText(record, line, reg, global)
+		if i == 0 {
+			fmt.Printf(" %s\n", line)
+		} else {
+			fmt.Printf("%s%s\n", strings.Repeat(" ", columns.Indent()), line)
+		}
+	}
+
+	if record.Parsed != nil {
+		dim := color.RGB(120, 120, 120)
+		for _, kv := range record.Parsed.Fields {
+			dim.Printf("%s%s=%s\n", strings.Repeat(" ", columns.Indent()), kv.Key, kv.Value)
+		}
+	}
+}
+
+// renderTUILine formats a record the way the plain writer would, as a
+// single highlighted line of text for --interactive's scrollback.
+func renderTUILine(record *record, styleCache *styleCache, reg *registry, global *filter.Set) tui.Line {
+	_, style := styleCache.getStyle(record.Source)
+
+	text := record.Line.Text
+	if record.Parsed != nil && record.Parsed.Message != "" {
+		text = record.Parsed.Message
+	}
+	text = highlightText(record, text, reg, global)
+
+	return tui.Line{
+		SourceName: record.Source.Name,
+		Text:       fmt.Sprintf("%s %s", style.Sprintf(" %s ", record.badgeName()), text),
+	}
+}
+
 func main() {
+	layoutFlag := flag.String("layout", "multiline", "default render layout: columns, inline, or multiline")
+	interactive := flag.Bool("interactive", false, "run in full-screen interactive mode")
+	flag.Parse()
+
+	globalLayout, err := layout.ParseMode(*layoutFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	config, err := loadConfig("nexus.json")
 	if err != nil {
 		log.Fatal(err)
@@ -202,42 +563,91 @@ func main() {
 
 	var wg sync.WaitGroup
 
-	files := make([]*tail.Tail, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+
 	records := make(chan *record, 1024)
+	filtered := make(chan *record, 1024)
+
+	reg := newRegistry()
+	styles := newStyleCache()
+	pipe := newPipeline(ctx, reg, records, &wg, styles)
 
 	for _, src := range config.Sources {
-		file, err := tail.TailFile(src.Path, tail.Config{Follow: true})
-		if err != nil {
-			fmt.Println(">>>>>>>>>>>>>>>>>>>>> error ", err)
-			continue
+		if err := pipe.start(src); err != nil {
+			fmt.Println("nexus: failed to start", src.Name, err)
 		}
-		files = append(files, file)
+	}
 
-		wg.Add(1)
-		go func(src *source, file *tail.Tail) {
-			for line := range file.Lines {
-				records <- &record{src, line}
-			}
+	global := filter.NewSet()
+	suppressed := newSuppressionCounts()
 
-			fmt.Printf(">>>>>>>>>>>>>>>>>>>>> stop for %s %v\n ", src.Path, file.Err())
-			wg.Done()
-		}(src, file)
-	}
+	go watchConfig("nexus.json", pipe.reload)
+
+	stopFilter := make(chan bool, 1)
+	go filterStage(records, filtered, reg, global, suppressed, stopFilter)
 
-	stopWriter := make(chan bool, 1)
-	go writer(records, stopWriter)
+	if *interactive {
+		runInteractive(config, filtered, reg, global, suppressed, styles)
+	} else {
+		go repl(global)
 
-	// Wait for interrupt.
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
-	<-interrupt
+		stopWriter := make(chan bool, 1)
+		go writer(filtered, stopWriter, globalLayout, reg, global, suppressed, styles)
 
-	stopWriter <- true
+		// Wait for interrupt.
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+		<-interrupt
 
-	for _, file := range files {
-		file.Stop()
-		file.Cleanup()
+		stopWriter <- true
 	}
 
+	stopFilter <- true
+
+	cancel()
+	pipe.stopAll()
+
 	wg.Wait()
 }
+
+// runInteractive drives the full-screen --interactive mode: it renders
+// filtered records into the TUI's ring buffer, periodically surfaces
+// suppressed-line summaries the same way writer does, and blocks until the
+// user quits (q or ctrl-c), at which point it returns so normal shutdown
+// (stopping tails, etc.) can proceed.
+func runInteractive(config *config, filtered <-chan *record, reg *registry, global *filter.Set, suppressed *suppressionCounts, styleCache *styleCache) {
+	sources := make([]tui.SourceInfo, len(config.Sources))
+	for i, src := range config.Sources {
+		r, g, b := sourceColor(src)
+		sources[i] = tui.SourceInfo{Name: src.Name, Background: [3]int{r, g, b}}
+	}
+
+	model := tui.New(sources, global.AddMatch)
+	program := tui.NewProgram(model)
+
+	lines := make(chan tui.Line, 1024)
+	go func() {
+		for rec := range filtered {
+			lines <- renderTUILine(rec, styleCache, reg, global)
+		}
+	}()
+
+	go func() {
+		summary := time.NewTicker(5 * time.Second)
+		defer summary.Stop()
+		for range summary.C {
+			for name, n := range suppressed.drain() {
+				lines <- tui.Line{
+					SourceName: name,
+					Text:       color.RGB(120, 120, 120).Sprintf(" %d lines suppressed from %s", n, name),
+				}
+			}
+		}
+	}()
+
+	go tui.Feed(program, lines)
+
+	if _, err := program.Run(); err != nil {
+		fmt.Println("nexus: interactive mode error:", err)
+	}
+}
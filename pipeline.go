@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gookit/color"
+)
+
+// pipeline owns the set of running tailers and lets a config reload add,
+// remove, or swap them in place without disturbing sources that didn't
+// change. tailers is guarded by mu since reload runs on the watcher
+// goroutine while stopAll runs on the main one; reg, which the tailer
+// forwarding goroutines read concurrently, has its own lock.
+type pipeline struct {
+	mu sync.Mutex
+
+	ctx     context.Context
+	reg     *registry
+	records chan<- *record
+	wg      *sync.WaitGroup
+	styles  *styleCache
+
+	tailers map[string]Source
+}
+
+func newPipeline(ctx context.Context, reg *registry, records chan<- *record, wg *sync.WaitGroup, styles *styleCache) *pipeline {
+	return &pipeline{
+		ctx:     ctx,
+		reg:     reg,
+		records: records,
+		wg:      wg,
+		styles:  styles,
+		tailers: make(map[string]Source),
+	}
+}
+
+// start brings up src for the first time: installs it in the registry,
+// starts its tailer, and forwards its lines into the shared records
+// channel, rewriting each record's Source to whatever the registry
+// currently holds for that name so later live reloads take effect without
+// restarting the tail.
+func (p *pipeline) start(src *source) error {
+	tailer := newTailSource(src, p.reg)
+	in, err := tailer.Start(p.ctx)
+	if err != nil {
+		return err
+	}
+
+	p.reg.put(src)
+
+	p.mu.Lock()
+	p.tailers[src.Name] = tailer
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func(name string) {
+		defer p.wg.Done()
+		for rec := range in {
+			if current := p.reg.get(name); current != nil {
+				rec.Source = current
+			}
+			p.records <- rec
+		}
+	}(src.Name)
+
+	return nil
+}
+
+// stopAll stops every running tailer, for final shutdown.
+func (p *pipeline) stopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, tailer := range p.tailers {
+		tailer.Stop()
+	}
+}
+
+// reload diffs newConfig against the running sources by name: sources
+// present only in newConfig are started, sources present only in the old
+// config are stopped, and sources present in both are either swapped in
+// place (settings, filters, colors) or stopped and restarted, depending on
+// whether restartRequired says the underlying path/command changed.
+func (p *pipeline) reload(newConfig *config) error {
+	updated := make(map[string]*source, len(newConfig.Sources))
+	for _, src := range newConfig.Sources {
+		updated[src.Name] = src
+	}
+
+	p.mu.Lock()
+	var added, removed, modified []string
+	for name := range p.tailers {
+		if _, ok := updated[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, name := range removed {
+		p.mu.Lock()
+		tailer := p.tailers[name]
+		delete(p.tailers, name)
+		p.mu.Unlock()
+
+		tailer.Stop()
+		p.reg.remove(name)
+		p.styles.invalidate(name)
+	}
+
+	for _, src := range newConfig.Sources {
+		old := p.reg.get(src.Name)
+
+		if old == nil {
+			if err := p.start(src); err != nil {
+				fmt.Printf("nexus: reload: failed to start %s: %v\n", src.Name, err)
+				continue
+			}
+			added = append(added, src.Name)
+			continue
+		}
+
+		if restartRequired(old, src) {
+			p.mu.Lock()
+			tailer := p.tailers[src.Name]
+			delete(p.tailers, src.Name)
+			p.mu.Unlock()
+
+			tailer.Stop()
+			if err := p.start(src); err != nil {
+				fmt.Printf("nexus: reload: failed to restart %s: %v\n", src.Name, err)
+				continue
+			}
+		} else {
+			p.reg.put(src)
+		}
+
+		if styleChanged(old, src) {
+			p.styles.invalidate(src.Name)
+		}
+
+		if restartRequired(old, src) || !equalSettings(old, src) {
+			modified = append(modified, src.Name)
+		}
+	}
+
+	printReloadBanner(added, removed, modified)
+
+	return nil
+}
+
+// equalSettings reports whether old and updated render and filter
+// identically, so a reload that only re-parses an unchanged config
+// doesn't get reported as a modification.
+func equalSettings(old, updated *source) bool {
+	return !styleChanged(old, updated) &&
+		old.Truncate == updated.Truncate &&
+		old.Layout == updated.Layout &&
+		old.Level == updated.Level &&
+		stringsEqual(old.Match, updated.Match) &&
+		stringsEqual(old.Exclude, updated.Exclude) &&
+		stringsEqual(old.Highlight, updated.Highlight)
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// printReloadBanner prints a subtle "reloaded: +added -removed ~modified"
+// summary, or nothing at all if the reload was a no-op.
+func printReloadBanner(added, removed, modified []string) {
+	if len(added) == 0 && len(removed) == 0 && len(modified) == 0 {
+		return
+	}
+
+	banner := "reloaded:"
+	for _, name := range added {
+		banner += " +" + name
+	}
+	for _, name := range removed {
+		banner += " -" + name
+	}
+	for _, name := range modified {
+		banner += " ~" + name
+	}
+
+	color.RGB(120, 120, 120).Println(banner)
+}
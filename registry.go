@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/schmich/nexus/filter"
+)
+
+// registry holds the live, hot-reloadable state for each configured
+// source, keyed by name rather than by *source pointer so a config reload
+// can swap a source's settings in place without the rest of the pipeline
+// (which may have cached state, like styleCache, against the old pointer)
+// losing track of it.
+type registry struct {
+	mu      sync.RWMutex
+	configs map[string]*source
+	sets    map[string]*filter.Set
+}
+
+func newRegistry() *registry {
+	return &registry{
+		configs: make(map[string]*source),
+		sets:    make(map[string]*filter.Set),
+	}
+}
+
+// put installs src as the current config for its name, recompiling its
+// filter set.
+func (r *registry) put(src *source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[src.Name] = src
+	r.sets[src.Name] = src.filterSet()
+}
+
+func (r *registry) remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.configs, name)
+	delete(r.sets, name)
+}
+
+func (r *registry) get(name string) *source {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.configs[name]
+}
+
+func (r *registry) filterSet(name string) *filter.Set {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sets[name]
+}
+
+// restartRequired reports whether changing from old to updated requires
+// stopping and restarting the tail/command, as opposed to a live swap:
+// anything that changes what process or file is actually being read.
+func restartRequired(old, updated *source) bool {
+	return old.Path != updated.Path ||
+		old.Command != updated.Command ||
+		old.Cwd != updated.Cwd ||
+		!reflect.DeepEqual(old.Args, updated.Args) ||
+		!reflect.DeepEqual(old.Env, updated.Env)
+}
+
+// styleChanged reports whether updated should get a freshly computed badge
+// color instead of keeping whatever styleCache has cached for this name.
+func styleChanged(old, updated *source) bool {
+	return !reflect.DeepEqual(old.Background, updated.Background) ||
+		!reflect.DeepEqual(old.Foreground, updated.Foreground)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hpcloud/tail"
+)
+
+// Source is implemented by each kind of log source nexus knows how to
+// start and stop: tailed files (source.Path) and spawned commands
+// (source.Command). Both feed the same *record stream so the rest of the
+// pipeline (filterStage, writer, tui) doesn't care which kind it's reading.
+type Source interface {
+	Start(ctx context.Context) (<-chan *record, error)
+	Stop()
+}
+
+// newTailSource picks the Source implementation for src. reg is threaded
+// through to commandSource so its restart policy stays current across a
+// live config swap (see commandSource.currentCfg).
+func newTailSource(src *source, reg *registry) Source {
+	if src.Command != "" {
+		return &commandSource{cfg: src, reg: reg}
+	}
+	return &fileSource{cfg: src}
+}
+
+type fileSource struct {
+	cfg  *source
+	file *tail.Tail
+}
+
+func (s *fileSource) Start(ctx context.Context) (<-chan *record, error) {
+	file, err := tail.TailFile(s.cfg.Path, tail.Config{Follow: true})
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+
+	out := make(chan *record, 64)
+	go func() {
+		defer close(out)
+		for line := range file.Lines {
+			out <- &record{Source: s.cfg, Line: line}
+		}
+		fmt.Printf(">>>>>>>>>>>>>>>>>>>>> stop for %s %v\n ", s.cfg.Path, file.Err())
+	}()
+
+	return out, nil
+}
+
+func (s *fileSource) Stop() {
+	if s.file == nil {
+		return
+	}
+	s.file.Stop()
+	s.file.Cleanup()
+}
+
+// commandSource spawns cfg.Command, tees its stdout and stderr into the
+// record stream (stderr tagged with the "stderr" sub-tag), and restarts it
+// per cfg.RestartOn ("never", "exit", "error") with exponential backoff
+// capped at cfg.MaxBackoff.
+type commandSource struct {
+	cfg    *source
+	reg    *registry
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// currentCfg returns reg's current config for this source if one is
+// registered, falling back to cfg. A "swap in place" reload (one that
+// doesn't change Path/Command/Cwd/Args/Env) only calls reg.put, so this is
+// what lets a running command pick up restart_on/max_backoff/grace_period
+// changes without a restart. The initial backoff is read once, when run
+// starts.
+func (s *commandSource) currentCfg() *source {
+	if s.reg != nil {
+		if current := s.reg.get(s.cfg.Name); current != nil {
+			return current
+		}
+	}
+	return s.cfg
+}
+
+func (s *commandSource) Start(ctx context.Context) (<-chan *record, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	out := make(chan *record, 64)
+	go func() {
+		defer close(out)
+		defer close(s.done)
+		s.run(ctx, out)
+	}()
+
+	return out, nil
+}
+
+// Stop cancels the running (or about-to-run) command and waits for its
+// goroutine to finish, so nexus doesn't exit while a child is still alive.
+func (s *commandSource) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+func (s *commandSource) run(ctx context.Context, out chan<- *record) {
+	backoff := s.backoffFloor()
+
+	for {
+		err := s.runOnce(ctx, out)
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		switch s.currentCfg().RestartOn {
+		case "exit":
+		case "error":
+			if err == nil {
+				return
+			}
+		default: // "never", ""
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if max := s.maxBackoff(); backoff > max {
+			backoff = max
+		}
+	}
+}
+
+func (s *commandSource) backoffFloor() time.Duration {
+	backoff := time.Duration(s.currentCfg().Backoff) * time.Second
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	return backoff
+}
+
+func (s *commandSource) maxBackoff() time.Duration {
+	maxBackoff := time.Duration(s.currentCfg().MaxBackoff) * time.Second
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	return maxBackoff
+}
+
+// runOnce spawns cfg.Command once and blocks until it exits or ctx is
+// canceled, in which case it sends SIGTERM, waits cfg.Grace, then SIGKILL.
+func (s *commandSource) runOnce(ctx context.Context, out chan<- *record) error {
+	cmd := exec.Command(s.cfg.Command, s.cfg.Args...)
+	cmd.Dir = s.cfg.Cwd
+	if len(s.cfg.Env) > 0 {
+		cmd.Env = append(os.Environ(), s.cfg.Env...)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var streams sync.WaitGroup
+	streams.Add(2)
+	go func() { defer streams.Done(); streamLines(stdout, s.cfg, "", out) }()
+	go func() { defer streams.Done(); streamLines(stderr, s.cfg, "stderr", out) }()
+
+	streamsDone := make(chan struct{})
+	go func() {
+		streams.Wait()
+		close(streamsDone)
+	}()
+
+	// cmd.Wait closes the stdout/stderr pipes' read ends once it sees the
+	// child exit, so it must not be called until streamLines has finished
+	// reading both of them, or trailing output can be truncated.
+	select {
+	case <-streamsDone:
+		return cmd.Wait()
+
+	case <-ctx.Done():
+		cmd.Process.Signal(syscall.SIGTERM)
+
+		select {
+		case <-streamsDone:
+			return cmd.Wait()
+		case <-time.After(graceDuration(s.currentCfg())):
+			cmd.Process.Kill()
+			<-streamsDone
+			return cmd.Wait()
+		}
+	}
+}
+
+func graceDuration(cfg *source) time.Duration {
+	if cfg.Grace <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(cfg.Grace) * time.Second
+}
+
+// streamLines reads r line by line, forwarding each as a record tagged
+// with subTag (e.g. "stderr"), until r is closed.
+func streamLines(r io.Reader, cfg *source, subTag string, out chan<- *record) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		out <- &record{
+			Source: cfg,
+			Line:   &tail.Line{Text: scanner.Text(), Time: time.Now()},
+			SubTag: subTag,
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package tui implements nexus's optional full-screen interactive mode:
+// a status bar of sources with their colors and enabled state, pause/
+// resume, per-source toggling, and a searchable scrollback ring buffer.
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gookit/color"
+)
+
+// SourceInfo is the subset of a source's config the status bar needs to
+// render its swatch and label.
+type SourceInfo struct {
+	Name       string
+	Background [3]int
+}
+
+// Line is a single rendered log line handed to the TUI. Text is the
+// rendered (colored, highlighted) text as one unwrapped line;
+// the TUI only adds the ring buffer, search, and pause semantics on top.
+type Line struct {
+	// SourceName is the underlying source's name, matching SourceInfo.Name,
+	// so the 1-9 toggles and status bar apply to a command's stderr
+	// sub-stream the same as its stdout.
+	SourceName string
+	Text       string
+}
+
+const defaultRingCapacity = 5000
+
+// Model is the bubbletea model driving --interactive mode.
+type Model struct {
+	sources []SourceInfo
+	enabled map[string]bool
+	order   []string
+
+	ringCap int
+	ring    []Line
+	dropped int
+
+	paused   bool
+	pausedAt int
+
+	mode        inputMode
+	input       string
+	searchQuery string
+
+	width, height int
+
+	pushFilter func(expr string) error
+}
+
+type inputMode int
+
+const (
+	modeNormal inputMode = iota
+	modeSearch
+	modeFilter
+)
+
+// New builds a Model for the given sources. pushFilter is called when the
+// user confirms an 'f' filter expression; it's expected to install the
+// expression on the running filter subsystem (e.g. the global filter.Set).
+func New(sources []SourceInfo, pushFilter func(expr string) error) *Model {
+	enabled := make(map[string]bool, len(sources))
+	order := make([]string, 0, len(sources))
+	for _, s := range sources {
+		enabled[s.Name] = true
+		order = append(order, s.Name)
+	}
+
+	return &Model{
+		sources:    sources,
+		enabled:    enabled,
+		order:      order,
+		ringCap:    defaultRingCapacity,
+		pushFilter: pushFilter,
+	}
+}
+
+// NewProgram wraps m in a bubbletea program set up for full-screen mode.
+// Start Feed before calling the program's Run so no records are missed.
+func NewProgram(m *Model) *tea.Program {
+	return tea.NewProgram(m, tea.WithAltScreen())
+}
+
+// recordMsg carries one tailed line into the bubbletea event loop.
+type recordMsg Line
+
+// Feed forwards records into the running program as they arrive. It should
+// be run in its own goroutine for the lifetime of the program.
+func Feed(program *tea.Program, records <-chan Line) {
+	for rec := range records {
+		program.Send(recordMsg(rec))
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case recordMsg:
+		m.push(Line(msg))
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+
+	return m, nil
+}
+
+func (m *Model) push(line Line) {
+	m.ring = append(m.ring, line)
+	if len(m.ring) > m.ringCap {
+		m.ring = m.ring[1:]
+		m.dropped++
+		if m.paused && m.pausedAt > 0 {
+			m.pausedAt--
+		}
+	}
+}
+
+func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.mode == modeSearch || m.mode == modeFilter {
+		return m.handleInputKey(msg)
+	}
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+
+	case " ":
+		m.paused = !m.paused
+		if m.paused {
+			m.pausedAt = len(m.ring)
+		}
+
+	case "/":
+		m.mode = modeSearch
+		m.input = ""
+
+	case "f":
+		m.mode = modeFilter
+		m.input = ""
+
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		idx := int(msg.String()[0] - '1')
+		if idx < len(m.order) {
+			name := m.order[idx]
+			m.enabled[name] = !m.enabled[name]
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Model) handleInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.mode = modeNormal
+		m.input = ""
+
+	case tea.KeyEnter:
+		switch m.mode {
+		case modeSearch:
+			m.searchQuery = m.input
+		case modeFilter:
+			if m.pushFilter != nil {
+				m.pushFilter(m.input)
+			}
+		}
+		m.mode = modeNormal
+		m.input = ""
+
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	}
+
+	return m, nil
+}
+
+func (m *Model) View() string {
+	var b strings.Builder
+
+	b.WriteString(m.statusBar())
+	b.WriteString("\n")
+
+	for _, line := range m.visibleLines() {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.footer())
+
+	return b.String()
+}
+
+func (m *Model) statusBar() string {
+	var b strings.Builder
+
+	for i, s := range m.sources {
+		bg := color.RGB(uint8(s.Background[0]), uint8(s.Background[1]), uint8(s.Background[2]), true)
+		label := fmt.Sprintf(" %d:%s ", i+1, s.Name)
+		if !m.enabled[s.Name] {
+			b.WriteString(fmt.Sprintf(" %d:%s(off) ", i+1, s.Name))
+			continue
+		}
+		b.WriteString(bg.Sprint(label))
+	}
+
+	if m.paused {
+		b.WriteString("  [PAUSED]")
+	}
+	if m.dropped > 0 {
+		b.WriteString(fmt.Sprintf("  [%d dropped]", m.dropped))
+	}
+
+	return b.String()
+}
+
+func (m *Model) footer() string {
+	switch m.mode {
+	case modeSearch:
+		return "/" + m.input
+	case modeFilter:
+		return "filter: " + m.input
+	default:
+		if m.searchQuery != "" {
+			return fmt.Sprintf("search: %s  (space: pause, 1-9: toggle source, /: search, f: filter, q: quit)", m.searchQuery)
+		}
+		return "space: pause, 1-9: toggle source, /: search, f: filter, q: quit"
+	}
+}
+
+// visibleLines returns the lines to draw in the scrollback area: from the
+// enabled sources, matching the active search (if any), highlighted, and
+// clipped to the terminal height. While paused the view doesn't advance,
+// but the underlying ring buffer keeps accepting (and, past capacity,
+// dropping) new lines in the background.
+func (m *Model) visibleLines() []string {
+	rows := m.height - 2
+	if rows < 1 {
+		rows = 20
+	}
+
+	ring := m.ring
+	if m.paused {
+		ring = ring[:m.pausedAt]
+	}
+
+	var matched []string
+	for _, line := range ring {
+		if !m.enabled[line.SourceName] {
+			continue
+		}
+		if m.searchQuery != "" && !strings.Contains(line.Text, m.searchQuery) {
+			continue
+		}
+		matched = append(matched, highlightSearch(line.Text, m.searchQuery))
+	}
+
+	if len(matched) > rows {
+		matched = matched[len(matched)-rows:]
+	}
+
+	return matched
+}
+
+func highlightSearch(text, query string) string {
+	if query == "" {
+		return text
+	}
+
+	idx := strings.Index(text, query)
+	if idx < 0 {
+		return text
+	}
+
+	return text[:idx] + "\x1b[7m" + text[idx:idx+len(query)] + "\x1b[0m" + text[idx+len(query):]
+}
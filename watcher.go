@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gookit/color"
+)
+
+// watchConfig watches filename for writes and re-invokes reload with the
+// freshly parsed config each time it changes. A config that fails to parse
+// or load is reported with a red error banner and otherwise ignored,
+// leaving whatever config is currently live running untouched. Runs until
+// the watcher fails to initialize; errors after that are only logged.
+func watchConfig(filename string, reload func(*config) error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Println("nexus: config watch disabled:", err)
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filename); err != nil {
+		fmt.Println("nexus: config watch disabled:", err)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			newConfig, err := loadConfig(filename)
+			if err != nil {
+				color.FgRed.Println("nexus: config reload failed:", err)
+				continue
+			}
+
+			if err := reload(newConfig); err != nil {
+				color.FgRed.Println("nexus: config reload failed:", err)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Println("nexus: config watch error:", err)
+		}
+	}
+}